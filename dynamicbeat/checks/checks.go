@@ -17,11 +17,14 @@ import (
 	"github.com/s-newman/scorestack/dynamicbeat/checks/http"
 	"github.com/s-newman/scorestack/dynamicbeat/checks/icmp"
 	"github.com/s-newman/scorestack/dynamicbeat/checks/imap"
+	"github.com/s-newman/scorestack/dynamicbeat/checks/kubernetes"
 	"github.com/s-newman/scorestack/dynamicbeat/checks/ldap"
 	"github.com/s-newman/scorestack/dynamicbeat/checks/noop"
+	"github.com/s-newman/scorestack/dynamicbeat/checks/oidc"
 	"github.com/s-newman/scorestack/dynamicbeat/checks/schema"
 	"github.com/s-newman/scorestack/dynamicbeat/checks/smtp"
 	"github.com/s-newman/scorestack/dynamicbeat/checks/ssh"
+	checktls "github.com/s-newman/scorestack/dynamicbeat/checks/tls"
 	"github.com/s-newman/scorestack/dynamicbeat/checks/vnc"
 	"github.com/s-newman/scorestack/dynamicbeat/checks/winrm"
 	"github.com/s-newman/scorestack/dynamicbeat/checks/xmpp"
@@ -105,8 +108,14 @@ func unpackDef(c schema.CheckDef) schema.Check {
 		def = &dns.Definition{}
 	case "ftp":
 		def = &ftp.Definition{}
+	case "kubernetes":
+		def = &kubernetes.Definition{}
 	case "ldap":
 		def = &ldap.Definition{}
+	case "oidc":
+		def = &oidc.Definition{}
+	case "tls":
+		def = &checktls.Definition{}
 	case "vnc":
 		def = &vnc.Definition{}
 	case "imap":
@@ -0,0 +1,306 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/s-newman/scorestack/dynamicbeat/checks/schema"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/clientcmd"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// The Definition configures the behavior of the Kubernetes check
+// it implements the "check" interface
+type Definition struct {
+	ID                    string // a unique identifier for this check
+	Name                  string // a human-readable title for the check
+	Group                 string // the group this check is part of
+	APIServer             string // (required) The URL of the Kubernetes API server
+	Kubeconfig            string // (required, unless BearerToken or ClientCertPEM/ClientKeyPEM are set) A kubeconfig file, as a YAML string
+	BearerToken           string // (required, unless Kubeconfig or ClientCertPEM/ClientKeyPEM are set) A bearer token to authenticate with
+	ClientCertPEM         string // (required with ClientKeyPEM, unless Kubeconfig or BearerToken are set) PEM-encoded client certificate
+	ClientKeyPEM          string // (required with ClientCertPEM, unless Kubeconfig or BearerToken are set) PEM-encoded client key
+	CAPEM                 string // (optional) PEM-encoded CA bundle used to verify the API server's certificate
+	Resource              string // (required) The resource type to check, e.g. "pods", "nodes", "deployments"
+	Namespace             string // (optional) The namespace to look in, for namespaced resources
+	ResourceName          string // (optional) The name of a single resource to Get; if unset, List is used and the assertion is applied to every item
+	ExpectedPhase         string // (optional) The status.phase the resource must have, e.g. "Running"
+	ExpectedReadyReplicas int32  // (optional) The minimum status.readyReplicas the resource must have
+}
+
+// Run a single instance of the check
+func (d *Definition) Run(wg *sync.WaitGroup, out chan<- schema.CheckResult) {
+	defer wg.Done()
+
+	// Set up result
+	result := schema.CheckResult{
+		Timestamp: time.Now(),
+		ID:        d.ID,
+		Name:      d.Name,
+		Group:     d.Group,
+		CheckType: "kubernetes",
+		Details:   make(map[string]string),
+	}
+
+	// Build the rest.Config from whichever auth method was configured
+	config, err := d.restConfig()
+	if err != nil {
+		result.Message = fmt.Sprintf("Failed to build Kubernetes client config : %s", err)
+		out <- result
+		return
+	}
+
+	// Confirm the API server is reachable before trying to fetch anything
+	disco, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		result.Message = fmt.Sprintf("Failed to create discovery client : %s", err)
+		out <- result
+		return
+	}
+	version, err := disco.ServerVersion()
+	if err != nil {
+		result.Message = fmt.Sprintf("Could not reach API server %s : %s", d.APIServer, err)
+		out <- result
+		return
+	}
+	result.Details["server_version"] = version.String()
+
+	// Build the typed clientset
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		result.Message = fmt.Sprintf("Failed to create Kubernetes client : %s", err)
+		out <- result
+		return
+	}
+
+	// Fetch and assert on the requested resource. If ResourceName is set, Get a single
+	// object; otherwise List and apply the assertion to every item returned.
+	switch d.Resource {
+	case "pods":
+		if d.ResourceName != "" {
+			pod, err := client.CoreV1().Pods(d.Namespace).Get(d.ResourceName, metav1.GetOptions{})
+			if err != nil {
+				result.Message = fmt.Sprintf("Failed to get pod %s/%s : %s", d.Namespace, d.ResourceName, err)
+				out <- result
+				return
+			}
+			result.Details["phase"] = string(pod.Status.Phase)
+			if msg := d.checkPodPhase(*pod); msg != "" {
+				result.Message = msg
+				out <- result
+				return
+			}
+			break
+		}
+
+		list, err := client.CoreV1().Pods(d.Namespace).List(metav1.ListOptions{})
+		if err != nil {
+			result.Message = fmt.Sprintf("Failed to list pods in %s : %s", d.Namespace, err)
+			out <- result
+			return
+		}
+		result.Details["count"] = fmt.Sprintf("%d", len(list.Items))
+		if len(list.Items) == 0 {
+			result.Message = fmt.Sprintf("No pods found in %s", d.Namespace)
+			out <- result
+			return
+		}
+		for _, pod := range list.Items {
+			if msg := d.checkPodPhase(pod); msg != "" {
+				result.Message = msg
+				out <- result
+				return
+			}
+		}
+
+	case "nodes":
+		if d.ResourceName != "" {
+			node, err := client.CoreV1().Nodes().Get(d.ResourceName, metav1.GetOptions{})
+			if err != nil {
+				result.Message = fmt.Sprintf("Failed to get node %s : %s", d.ResourceName, err)
+				out <- result
+				return
+			}
+			ready := nodeReady(*node)
+			result.Details["ready"] = fmt.Sprintf("%t", ready)
+			if !ready {
+				result.Message = fmt.Sprintf("Node %s is not Ready", d.ResourceName)
+				out <- result
+				return
+			}
+			break
+		}
+
+		list, err := client.CoreV1().Nodes().List(metav1.ListOptions{})
+		if err != nil {
+			result.Message = fmt.Sprintf("Failed to list nodes : %s", err)
+			out <- result
+			return
+		}
+		result.Details["count"] = fmt.Sprintf("%d", len(list.Items))
+		if len(list.Items) == 0 {
+			result.Message = "No nodes found"
+			out <- result
+			return
+		}
+		for _, node := range list.Items {
+			if !nodeReady(node) {
+				result.Message = fmt.Sprintf("Node %s is not Ready", node.Name)
+				out <- result
+				return
+			}
+		}
+
+	case "deployments":
+		if d.ResourceName != "" {
+			deploy, err := client.AppsV1().Deployments(d.Namespace).Get(d.ResourceName, metav1.GetOptions{})
+			if err != nil {
+				result.Message = fmt.Sprintf("Failed to get deployment %s/%s : %s", d.Namespace, d.ResourceName, err)
+				out <- result
+				return
+			}
+			result.Details["ready_replicas"] = fmt.Sprintf("%d", deploy.Status.ReadyReplicas)
+			if msg := d.checkDeploymentReplicas(*deploy); msg != "" {
+				result.Message = msg
+				out <- result
+				return
+			}
+			break
+		}
+
+		list, err := client.AppsV1().Deployments(d.Namespace).List(metav1.ListOptions{})
+		if err != nil {
+			result.Message = fmt.Sprintf("Failed to list deployments in %s : %s", d.Namespace, err)
+			out <- result
+			return
+		}
+		result.Details["count"] = fmt.Sprintf("%d", len(list.Items))
+		if len(list.Items) == 0 {
+			result.Message = fmt.Sprintf("No deployments found in %s", d.Namespace)
+			out <- result
+			return
+		}
+		for _, deploy := range list.Items {
+			if msg := d.checkDeploymentReplicas(deploy); msg != "" {
+				result.Message = msg
+				out <- result
+				return
+			}
+		}
+
+	default:
+		result.Message = fmt.Sprintf("Unsupported Resource type %q", d.Resource)
+		out <- result
+		return
+	}
+
+	// If we reached here the check passes
+	result.Passed = true
+	out <- result
+}
+
+// checkPodPhase returns a failure message if pod's phase doesn't match ExpectedPhase, or "" if it passes
+func (d *Definition) checkPodPhase(pod corev1.Pod) string {
+	if d.ExpectedPhase != "" && string(pod.Status.Phase) != d.ExpectedPhase {
+		return fmt.Sprintf("Pod %s/%s has phase %s, expected %s", pod.Namespace, pod.Name, pod.Status.Phase, d.ExpectedPhase)
+	}
+	return ""
+}
+
+// checkDeploymentReplicas returns a failure message if deploy doesn't have enough ready
+// replicas, or "" if it passes
+func (d *Definition) checkDeploymentReplicas(deploy appsv1.Deployment) string {
+	if deploy.Status.ReadyReplicas < d.ExpectedReadyReplicas {
+		return fmt.Sprintf(
+			"Deployment %s/%s has %d ready replicas, expected at least %d",
+			deploy.Namespace, deploy.Name, deploy.Status.ReadyReplicas, d.ExpectedReadyReplicas,
+		)
+	}
+	return ""
+}
+
+// nodeReady reports whether node has a NodeReady condition with status True
+func nodeReady(node corev1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// restConfig builds a *rest.Config from whichever auth method was configured
+func (d *Definition) restConfig() (*rest.Config, error) {
+	if d.Kubeconfig != "" {
+		return clientcmd.RESTConfigFromKubeConfig([]byte(d.Kubeconfig))
+	}
+
+	config := &rest.Config{
+		Host: d.APIServer,
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: []byte(d.CAPEM),
+		},
+	}
+
+	if d.BearerToken != "" {
+		config.BearerToken = d.BearerToken
+		return config, nil
+	}
+
+	config.TLSClientConfig.CertData = []byte(d.ClientCertPEM)
+	config.TLSClientConfig.KeyData = []byte(d.ClientKeyPEM)
+	return config, nil
+}
+
+// Init the check using a known ID and name. The rest of the check fields will
+// be filled in by parsing a JSON string representing the check definition.
+func (d *Definition) Init(id string, name string, group string, def []byte) error {
+
+	// Unpack JSON definition
+	err := json.Unmarshal(def, &d)
+	if err != nil {
+		return err
+	}
+
+	// Set generic values
+	d.ID = id
+	d.Name = name
+	d.Group = group
+
+	// Check for missing fields
+	missingFields := make([]string, 0)
+	if d.APIServer == "" {
+		missingFields = append(missingFields, "APIServer")
+	}
+
+	if d.Kubeconfig == "" && d.BearerToken == "" {
+		switch {
+		case d.ClientCertPEM == "" && d.ClientKeyPEM == "":
+			missingFields = append(missingFields, "Kubeconfig")
+		case d.ClientCertPEM == "":
+			missingFields = append(missingFields, "ClientCertPEM")
+		case d.ClientKeyPEM == "":
+			missingFields = append(missingFields, "ClientKeyPEM")
+		}
+	}
+
+	if d.Resource == "" {
+		missingFields = append(missingFields, "Resource")
+	}
+
+	if len(missingFields) > 0 {
+		return schema.ValidationError{
+			ID:    d.ID,
+			Type:  "kubernetes",
+			Field: missingFields[0],
+		}
+	}
+	return nil
+}
@@ -2,8 +2,11 @@ package ldap
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"regexp"
 	"sync"
 	"time"
 
@@ -14,14 +17,22 @@ import (
 // The Definition configures the behavior of the SSH check
 // it implements the "check" interface
 type Definition struct {
-	ID       string // a unique identifier for this check
-	Name     string // a human-readable title for the check
-	Group    string // the group this check is part of
-	User     string // (required) The user written in DN syntax
-	Password string // (required) the password for the user
-	Fqdn     string // (required) The Fqdn of the ldap server
-	Ldaps    bool   // (optional, default=false) Whether or not to use LDAP+TLS
-	Port     string // (optional, default=389) Port for ldap
+	ID            string            // a unique identifier for this check
+	Name          string            // a human-readable title for the check
+	Group         string            // the group this check is part of
+	User          string            // (required) The user written in DN syntax
+	Password      string            // (required) the password for the user
+	Fqdn          string            // (required) The Fqdn of the ldap server
+	Ldaps         bool              // (optional, default=false) Whether or not to use StartTLS on the default ldap port
+	UseLDAPS      bool              // (optional, default=false) Whether or not to dial directly over LDAP+TLS on the ldaps port
+	CAFile        string            // (optional) Path to a PEM-encoded CA bundle file used to verify the server's certificate
+	ServerName    string            // (optional, default=Fqdn) Server name to verify the certificate against
+	Port          string            // (optional, default=389, or 636 if UseLDAPS) Port for ldap
+	BaseDN        string            // (optional) The base DN to search under
+	Filter        string            // (optional, default=`(objectClass=*)`) The search filter to apply
+	Scope         string            // (optional, default="sub") The search scope : "base", "one", or "sub"
+	Attributes    []string          // (optional) The attributes to request from matched entries
+	ExpectedAttrs map[string]string // (optional) Attribute name to regex that the returned value must match
 }
 
 // Run a single instance of the check
@@ -34,13 +45,27 @@ func (d *Definition) Run(wg *sync.WaitGroup, out chan<- schema.CheckResult) {
 		ID:        d.ID,
 		Group:     d.Group,
 		CheckType: "ldap",
+		Details:   make(map[string]string),
 	}
 
 	// Set timeout
 	ldap.DefaultTimeout = 5 * time.Second
 
-	// Normal, default ldap check
-	lconn, err := ldap.Dial("tcp", fmt.Sprintf("%s:%s", d.Fqdn, d.Port))
+	// Build the TLS config used for both LDAPS and StartTLS
+	tlsConfig, err := d.tlsConfig()
+	if err != nil {
+		result.Message = fmt.Sprintf("Failed to build TLS config : %s", err)
+		out <- result
+		return
+	}
+
+	// Dial the server, either directly over TLS (ldaps) or in the clear (with StartTLS to follow)
+	var lconn *ldap.Conn
+	if d.UseLDAPS {
+		lconn, err = ldap.DialTLS("tcp", fmt.Sprintf("%s:%s", d.Fqdn, d.Port), tlsConfig)
+	} else {
+		lconn, err = ldap.Dial("tcp", fmt.Sprintf("%s:%s", d.Fqdn, d.Port))
+	}
 	if err != nil {
 		result.Message = fmt.Sprintf("Could not dial server %s : %s", d.Fqdn, err)
 		out <- result
@@ -51,9 +76,9 @@ func (d *Definition) Run(wg *sync.WaitGroup, out chan<- schema.CheckResult) {
 	// Set message timeout
 	lconn.SetTimeout(5 * time.Second)
 
-	// Add TLS if needed
+	// Add StartTLS if needed
 	if d.Ldaps {
-		err = lconn.StartTLS(&tls.Config{InsecureSkipVerify: true})
+		err = lconn.StartTLS(tlsConfig)
 		if err != nil {
 			result.Message = fmt.Sprintf("TLS session creation failed : %s", err)
 			out <- result
@@ -69,17 +94,112 @@ func (d *Definition) Run(wg *sync.WaitGroup, out chan<- schema.CheckResult) {
 		return
 	}
 
+	// If no BaseDN was given, binding successfully is enough to pass
+	if d.BaseDN == "" {
+		result.Passed = true
+		out <- result
+		return
+	}
+
+	// Search the directory
+	req := ldap.NewSearchRequest(
+		d.BaseDN,
+		searchScope(d.Scope),
+		ldap.NeverDerefAliases,
+		0,
+		0,
+		false,
+		d.Filter,
+		d.Attributes,
+		nil,
+	)
+	sr, err := lconn.Search(req)
+	if err != nil {
+		result.Message = fmt.Sprintf("Search under %s failed : %s", d.BaseDN, err)
+		out <- result
+		return
+	}
+
+	if len(sr.Entries) == 0 {
+		result.Message = fmt.Sprintf("Search under %s with filter %s returned no entries", d.BaseDN, d.Filter)
+		out <- result
+		return
+	}
+
+	// Check the returned attributes against ExpectedAttrs
+	entry := sr.Entries[0]
+	for name, pattern := range d.ExpectedAttrs {
+		value := entry.GetAttributeValue(name)
+		result.Details[name] = value
+
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			result.Message = fmt.Sprintf("Error compiling regex string %s for attribute %s : %s", pattern, name, err)
+			out <- result
+			return
+		}
+
+		if !regex.MatchString(value) {
+			result.Message = fmt.Sprintf("Attribute %s value %q did not match expected pattern %q", name, value, pattern)
+			out <- result
+			return
+		}
+	}
+
+	// Record any other requested attributes for visibility, even if not asserted on
+	for _, name := range d.Attributes {
+		if _, ok := result.Details[name]; !ok {
+			result.Details[name] = entry.GetAttributeValue(name)
+		}
+	}
+
 	// If we reached here the check passes
 	result.Passed = true
 	out <- result
 }
 
+// tlsConfig builds the *tls.Config used when dialing over LDAPS or issuing StartTLS
+func (d *Definition) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{ServerName: d.ServerName}
+
+	if d.CAFile == "" {
+		return config, nil
+	}
+
+	pem, err := ioutil.ReadFile(d.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read CAFile : %s", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("could not parse CAFile as PEM")
+	}
+	config.RootCAs = pool
+
+	return config, nil
+}
+
+// searchScope converts the configured Scope string into the ldap package's scope constant
+func searchScope(scope string) int {
+	switch scope {
+	case "base":
+		return ldap.ScopeBaseObject
+	case "one":
+		return ldap.ScopeSingleLevel
+	default:
+		return ldap.ScopeWholeSubtree
+	}
+}
+
 // Init the check using a known ID and name. The rest of the check fields will
 // be filled in by parsing a JSON string representing the check definition.
 func (d *Definition) Init(id string, name string, group string, def []byte) error {
 
 	// Explicitly set default values
 	d.Port = "389"
+	d.Filter = "(objectClass=*)"
+	d.Scope = "sub"
 
 	// Unpack JSON definition
 	err := json.Unmarshal(def, &d)
@@ -92,6 +212,27 @@ func (d *Definition) Init(id string, name string, group string, def []byte) erro
 	d.Name = name
 	d.Group = group
 
+	// Switch to the LDAPS port if LDAPS was requested and the port is 389, whether
+	// that 389 is the default or was given explicitly
+	if d.UseLDAPS && d.Port == "389" {
+		d.Port = "636"
+	}
+
+	// Default ServerName to Fqdn for certificate verification
+	if d.ServerName == "" {
+		d.ServerName = d.Fqdn
+	}
+
+	// Ldaps (StartTLS) and UseLDAPS (dial directly over TLS) are mutually exclusive : the
+	// ldap.v2 client rejects a StartTLS call on a connection that's already encrypted
+	if d.Ldaps && d.UseLDAPS {
+		return schema.ValidationError{
+			ID:    d.ID,
+			Type:  "ldap",
+			Field: "Ldaps (cannot be combined with UseLDAPS)",
+		}
+	}
+
 	// Check for missing fields
 	missingFields := make([]string, 0)
 	if d.User == "" {
@@ -114,4 +255,4 @@ func (d *Definition) Init(id string, name string, group string, def []byte) erro
 		}
 	}
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,188 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc"
+	"github.com/s-newman/scorestack/dynamicbeat/checks/schema"
+	"golang.org/x/oauth2"
+)
+
+// The Definition configures the behavior of the OIDC check
+// it implements the "check" interface
+type Definition struct {
+	ID             string            // a unique identifier for this check
+	Name           string            // a human-readable title for the check
+	Group          string            // the group this check is part of
+	IssuerURL      string            // (required) The base URL of the OIDC/OAuth2 provider
+	ClientID       string            // (required) The OAuth2 client ID
+	ClientSecret   string            // (required) The OAuth2 client secret
+	Username       string            // (required) The resource owner's username
+	Password       string            // (required) The resource owner's password
+	Scopes         []string          // (optional, default=["openid", "profile", "email"]) Scopes to request
+	ExpectedClaims map[string]string // (optional) Claim name to the value it must have in the ID token
+}
+
+// Run a single instance of the check
+func (d *Definition) Run(wg *sync.WaitGroup, out chan<- schema.CheckResult) {
+	defer wg.Done()
+
+	// Set up result
+	result := schema.CheckResult{
+		Timestamp: time.Now(),
+		ID:        d.ID,
+		Name:      d.Name,
+		Group:     d.Group,
+		CheckType: "oidc",
+		Details:   make(map[string]string),
+	}
+
+	// Set up a client with a short timeout for the discovery and token requests
+	client := &http.Client{Timeout: 5 * time.Second}
+	ctx := oidc.ClientContext(context.Background(), client)
+
+	// Fetch the discovery document and validate it exposes the endpoints we need
+	provider, err := oidc.NewProvider(ctx, d.IssuerURL)
+	if err != nil {
+		result.Message = fmt.Sprintf("Failed to fetch discovery document from %s : %s", d.IssuerURL, err)
+		out <- result
+		return
+	}
+
+	var endpoints struct {
+		TokenURL string `json:"token_endpoint"`
+		JWKSURL  string `json:"jwks_uri"`
+	}
+	if err := provider.Claims(&endpoints); err != nil {
+		result.Message = fmt.Sprintf("Discovery document missing required endpoints : %s", err)
+		out <- result
+		return
+	}
+	if endpoints.TokenURL == "" || endpoints.JWKSURL == "" {
+		result.Message = "Discovery document did not advertise a token_endpoint or jwks_uri"
+		out <- result
+		return
+	}
+
+	// Perform a Resource Owner Password Credentials grant against the token endpoint
+	oauth2Conf := oauth2.Config{
+		ClientID:     d.ClientID,
+		ClientSecret: d.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       d.Scopes,
+	}
+	token, err := oauth2Conf.PasswordCredentialsToken(ctx, d.Username, d.Password)
+	if err != nil {
+		result.Message = fmt.Sprintf("Password credentials grant failed for user %s : %s", d.Username, err)
+		out <- result
+		return
+	}
+
+	// Pull the ID token out of the token response
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		result.Message = "Token response did not contain an id_token"
+		out <- result
+		return
+	}
+
+	// Validate the ID token's signature and standard claims
+	verifier := provider.Verifier(&oidc.Config{ClientID: d.ClientID})
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		result.Message = fmt.Sprintf("ID token verification failed : %s", err)
+		out <- result
+		return
+	}
+	result.Details["iss"] = idToken.Issuer
+	result.Details["sub"] = idToken.Subject
+	result.Details["expiry"] = idToken.Expiry.String()
+
+	// Optionally assert that the claims contain the expected values
+	if len(d.ExpectedClaims) > 0 {
+		var claims map[string]interface{}
+		if err := idToken.Claims(&claims); err != nil {
+			result.Message = fmt.Sprintf("Failed to parse ID token claims : %s", err)
+			out <- result
+			return
+		}
+
+		for key, want := range d.ExpectedClaims {
+			got, ok := claims[key]
+			if !ok {
+				result.Message = fmt.Sprintf("Expected claim %s was not present in the ID token", key)
+				out <- result
+				return
+			}
+			gotStr := fmt.Sprintf("%v", got)
+			if gotStr != want {
+				result.Message = fmt.Sprintf("Expected claim %s to be %q, got %q", key, want, gotStr)
+				out <- result
+				return
+			}
+		}
+	}
+
+	// If we reached here the check passes
+	result.Passed = true
+	out <- result
+}
+
+// Init the check using a known ID and name. The rest of the check fields will
+// be filled in by parsing a JSON string representing the check definition.
+func (d *Definition) Init(id string, name string, group string, def []byte) error {
+
+	// Explicitly set default values
+	d.Scopes = []string{"openid", "profile", "email"}
+
+	// Unpack JSON definition
+	err := json.Unmarshal(def, &d)
+	if err != nil {
+		return err
+	}
+
+	// Set generic values
+	d.ID = id
+	d.Name = name
+	d.Group = group
+
+	// Strip any trailing slash so we can cleanly join the well-known path
+	d.IssuerURL = strings.TrimSuffix(d.IssuerURL, "/")
+
+	// Check for missing fields
+	missingFields := make([]string, 0)
+	if d.IssuerURL == "" {
+		missingFields = append(missingFields, "IssuerURL")
+	}
+
+	if d.ClientID == "" {
+		missingFields = append(missingFields, "ClientID")
+	}
+
+	if d.ClientSecret == "" {
+		missingFields = append(missingFields, "ClientSecret")
+	}
+
+	if d.Username == "" {
+		missingFields = append(missingFields, "Username")
+	}
+
+	if d.Password == "" {
+		missingFields = append(missingFields, "Password")
+	}
+
+	if len(missingFields) > 0 {
+		return schema.ValidationError{
+			ID:    d.ID,
+			Type:  "oidc",
+			Field: missingFields[0],
+		}
+	}
+	return nil
+}
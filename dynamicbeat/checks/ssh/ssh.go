@@ -3,27 +3,39 @@ package ssh
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
 	"regexp"
 	"sync"
 	"time"
 
+	"github.com/pkg/sftp"
 	"github.com/s-newman/scorestack/dynamicbeat/checks/schema"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 // The Definition configures the behavior of the SSH check
 // it implements the "check" interface
 type Definition struct {
-	ID           string // unique identifier for this check
-	Name         string // a human-readable title for the check
-	Group        string // (required) The group ID
-	IP           string // (required) IP of the host to run the ICMP check against
-	Username     string // (required) The user to login with over ssh
-	Password     string // (required) The password for the user that you wish to login with
-	Cmd          string // (required) The command to execute once ssh connection established
-	MatchContent bool   // (optional, default=false) Whether or not to match content like checking files
-	ContentRegex string // (optional, default=`.*`) Regex to match if reading a file
-	Port         string // (optional, default=22) The port to attempt an ssh connection on
+	ID             string // unique identifier for this check
+	Name           string // a human-readable title for the check
+	Group          string // (required) The group ID
+	IP             string // (required) IP of the host to run the ICMP check against
+	Username       string // (required) The user to login with over ssh
+	Password       string // (required if AuthMethod is "password") The password for the user that you wish to login with
+	Cmd            string // (required, unless SFTPPath is set) The command to execute once ssh connection established
+	MatchContent   bool   // (optional, default=false) Whether or not to match content like checking files
+	ContentRegex   string // (optional, default=`.*`) Regex to match if reading a file
+	Port           string // (optional, default=22) The port to attempt an ssh connection on
+	AuthMethod     string // (optional, default="password") How to authenticate : "password", "publickey", or "agent"
+	PrivateKey     string // (required if AuthMethod is "publickey") PEM-encoded private key
+	Passphrase     string // (optional) Passphrase protecting PrivateKey
+	KnownHostsFile string // (optional, default=InsecureIgnoreHostKey) Path to a known_hosts file used to pin the host key
+	SFTPPath       string // (optional) If set, fetch this file over SFTP and match ContentRegex against its contents instead of running Cmd
 }
 
 // Run a single instance of the check
@@ -38,13 +50,30 @@ func (d *Definition) Run(wg *sync.WaitGroup, out chan<- schema.CheckResult) {
 		CheckType: "ssh",
 	}
 
+	// Build the auth method requested by AuthMethod
+	auth, agentConn, err := d.authMethod()
+	if err != nil {
+		result.Message = fmt.Sprintf("Error building auth method %s : %s", d.AuthMethod, err)
+		out <- result
+		return
+	}
+	if agentConn != nil {
+		defer agentConn.Close()
+	}
+
+	// Build the host key callback, pinning to KnownHostsFile if one was given
+	hostKeyCallback, err := d.hostKeyCallback()
+	if err != nil {
+		result.Message = fmt.Sprintf("Error loading known hosts file %s : %s", d.KnownHostsFile, err)
+		out <- result
+		return
+	}
+
 	// Config SSH client
 	config := &ssh.ClientConfig{
-		User: d.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(d.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		User:            d.Username,
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: hostKeyCallback,
 		Timeout:         5 * time.Second,
 	}
 
@@ -55,6 +84,34 @@ func (d *Definition) Run(wg *sync.WaitGroup, out chan<- schema.CheckResult) {
 		out <- result
 		return
 	}
+	defer client.Close()
+
+	// If an SFTPPath was given, fetch that file over SFTP instead of running Cmd
+	if d.SFTPPath != "" {
+		output, err := d.fetchSFTP(client)
+		if err != nil {
+			result.Message = fmt.Sprintf("Error fetching %s over SFTP: %s", d.SFTPPath, err)
+			out <- result
+			return
+		}
+
+		regex, err := regexp.Compile(d.ContentRegex)
+		if err != nil {
+			result.Message = fmt.Sprintf("Error compiling regex string %s : %s", d.ContentRegex, err)
+			out <- result
+			return
+		}
+
+		if !regex.Match(output) {
+			result.Message = fmt.Sprintf("Matching content not found")
+			out <- result
+			return
+		}
+
+		result.Passed = true
+		out <- result
+		return
+	}
 
 	// Create a session from the connection
 	session, err := client.NewSession()
@@ -102,6 +159,62 @@ func (d *Definition) Run(wg *sync.WaitGroup, out chan<- schema.CheckResult) {
 	out <- result
 }
 
+// authMethod builds the ssh.AuthMethod requested by d.AuthMethod. If a connection to an
+// external agent was opened to build it, that connection is also returned so the caller
+// can close it once it's no longer needed; otherwise the returned io.Closer is nil.
+func (d *Definition) authMethod() (ssh.AuthMethod, io.Closer, error) {
+	switch d.AuthMethod {
+	case "publickey":
+		var signer ssh.Signer
+		var err error
+		if d.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(d.PrivateKey), []byte(d.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(d.PrivateKey))
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not parse private key : %s", err)
+		}
+		return ssh.PublicKeys(signer), nil, nil
+	case "agent":
+		sock, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not connect to ssh-agent : %s", err)
+		}
+		return ssh.PublicKeysCallback(agent.NewClient(sock).Signers), sock, nil
+	case "", "password":
+		return ssh.Password(d.Password), nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown AuthMethod %q", d.AuthMethod)
+	}
+}
+
+// hostKeyCallback builds the ssh.HostKeyCallback used to verify the server's
+// host key, pinning against KnownHostsFile if one was provided
+func (d *Definition) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if d.KnownHostsFile == "" {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	return knownhosts.New(d.KnownHostsFile)
+}
+
+// fetchSFTP opens an SFTP session over client and returns the contents of d.SFTPPath
+func (d *Definition) fetchSFTP(client *ssh.Client) ([]byte, error) {
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		return nil, fmt.Errorf("could not start sftp session : %s", err)
+	}
+	defer sftpClient.Close()
+
+	f, err := sftpClient.Open(d.SFTPPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open remote file : %s", err)
+	}
+	defer f.Close()
+
+	return ioutil.ReadAll(f)
+}
+
 // Init the check using a known ID and name. The rest of the check fields will
 // be filled in by parsing a JSON string representing the check definition.
 func (d *Definition) Init(id string, name string, def []byte) error {
@@ -126,6 +239,11 @@ func (d *Definition) Init(id string, name string, def []byte) error {
 		d.ContentRegex = ".*"
 	}
 
+	// Check for optional AuthMethod
+	if d.AuthMethod == "" {
+		d.AuthMethod = "password"
+	}
+
 	// Check for missing fields
 	missingFields := make([]string, 0)
 	if d.IP == "" {
@@ -136,11 +254,15 @@ func (d *Definition) Init(id string, name string, def []byte) error {
 		missingFields = append(missingFields, "Username")
 	}
 
-	if d.Password == "" {
+	if d.AuthMethod == "password" && d.Password == "" {
 		missingFields = append(missingFields, "Password")
 	}
 
-	if d.Cmd == "" {
+	if d.AuthMethod == "publickey" && d.PrivateKey == "" {
+		missingFields = append(missingFields, "PrivateKey")
+	}
+
+	if d.Cmd == "" && d.SFTPPath == "" {
 		missingFields = append(missingFields, "Cmd")
 	}
 
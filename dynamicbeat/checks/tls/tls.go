@@ -0,0 +1,164 @@
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/s-newman/scorestack/dynamicbeat/checks/schema"
+)
+
+// The Definition configures the behavior of the TLS check
+// it implements the "check" interface
+type Definition struct {
+	ID               string   // a unique identifier for this check
+	Name             string   // a human-readable title for the check
+	Group            string   // the group this check is part of
+	Host             string   // (required) The host to connect to
+	Port             string   // (required) The port to connect to
+	SNI              string   // (optional, default=Host) Server name to send for SNI
+	CABundle         string   // (optional, default=system roots) PEM-encoded CA bundle to verify the chain against
+	ExpectedCN       string   // (optional) Subject common name the leaf certificate must have
+	ExpectedSANs     []string // (optional) DNS SANs the leaf certificate must have
+	MinDaysRemaining int      // (optional, default=7) Minimum number of days before expiry that still counts as passing
+}
+
+// Run a single instance of the check
+func (d *Definition) Run(wg *sync.WaitGroup, out chan<- schema.CheckResult) {
+	defer wg.Done()
+
+	// Set up result
+	result := schema.CheckResult{
+		Timestamp: time.Now(),
+		ID:        d.ID,
+		Name:      d.Name,
+		Group:     d.Group,
+		CheckType: "tls",
+		Details:   make(map[string]string),
+	}
+
+	// Build the root CA pool, falling back to the system roots
+	var roots *x509.CertPool
+	if d.CABundle != "" {
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM([]byte(d.CABundle)) {
+			result.Message = "Failed to parse CABundle PEM"
+			out <- result
+			return
+		}
+	}
+
+	// Dial the host, letting the tls package perform chain validation against roots
+	config := &tls.Config{
+		ServerName: d.SNI,
+		RootCAs:    roots,
+	}
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", fmt.Sprintf("%s:%s", d.Host, d.Port), config)
+	if err != nil {
+		result.Message = fmt.Sprintf("Could not establish a TLS connection to %s:%s : %s", d.Host, d.Port, err)
+		out <- result
+		return
+	}
+	defer conn.Close()
+
+	// Grab the leaf certificate from the verified chain
+	state := conn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		result.Message = "Server did not present any certificates"
+		out <- result
+		return
+	}
+	leaf := state.PeerCertificates[0]
+
+	// Populate details so scoreboards can display expiry countdowns
+	daysRemaining := int(time.Until(leaf.NotAfter).Hours() / 24)
+	result.Details["issuer"] = leaf.Issuer.String()
+	result.Details["subject"] = leaf.Subject.String()
+	result.Details["serial"] = leaf.SerialNumber.String()
+	result.Details["not_before"] = leaf.NotBefore.String()
+	result.Details["not_after"] = leaf.NotAfter.String()
+	result.Details["days_remaining"] = strconv.Itoa(daysRemaining)
+
+	// Check expiry against the configured threshold
+	if daysRemaining < d.MinDaysRemaining {
+		result.Message = fmt.Sprintf("Certificate for %s expires in %d days, less than the required %d", d.Host, daysRemaining, d.MinDaysRemaining)
+		out <- result
+		return
+	}
+
+	// Optionally assert the subject CN
+	if d.ExpectedCN != "" && leaf.Subject.CommonName != d.ExpectedCN {
+		result.Message = fmt.Sprintf("Expected CN %q, got %q", d.ExpectedCN, leaf.Subject.CommonName)
+		out <- result
+		return
+	}
+
+	// Optionally assert the DNS SANs
+	for _, want := range d.ExpectedSANs {
+		found := false
+		for _, got := range leaf.DNSNames {
+			if got == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result.Message = fmt.Sprintf("Expected SAN %q not found in certificate", want)
+			out <- result
+			return
+		}
+	}
+
+	// If we reached here the check passes
+	result.Passed = true
+	out <- result
+}
+
+// Init the check using a known ID and name. The rest of the check fields will
+// be filled in by parsing a JSON string representing the check definition.
+func (d *Definition) Init(id string, name string, group string, def []byte) error {
+
+	// Explicitly set default values
+	d.MinDaysRemaining = 7
+
+	// Unpack JSON definition
+	err := json.Unmarshal(def, &d)
+	if err != nil {
+		return err
+	}
+
+	// Set generic values
+	d.ID = id
+	d.Name = name
+	d.Group = group
+
+	// Default the SNI name to the host being dialed
+	if d.SNI == "" {
+		d.SNI = d.Host
+	}
+
+	// Check for missing fields
+	missingFields := make([]string, 0)
+	if d.Host == "" {
+		missingFields = append(missingFields, "Host")
+	}
+
+	if d.Port == "" {
+		missingFields = append(missingFields, "Port")
+	}
+
+	if len(missingFields) > 0 {
+		return schema.ValidationError{
+			ID:    d.ID,
+			Type:  "tls",
+			Field: missingFields[0],
+		}
+	}
+	return nil
+}